@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/codegangsta/cli"
+	rspec "github.com/opencontainers/runtime-spec/specs-go"
+
+	"github.com/opencontainers/ocitools/generate"
+	"github.com/opencontainers/ocitools/generate/seccomp"
+)
+
+var generateFlags = []cli.Flag{
+	cli.StringFlag{Name: "output", Value: "config.json", Usage: "output file, or \"-\" for stdout"},
+	cli.StringFlag{Name: "rootfs", Value: "rootfs", Usage: "path to the root filesystem, relative to the bundle"},
+	cli.BoolFlag{Name: "read-only", Usage: "mount the root filesystem read-only"},
+	cli.StringFlag{Name: "hostname", Usage: "container's hostname"},
+	cli.StringFlag{Name: "cwd", Usage: "working directory of the container process"},
+	cli.StringSliceFlag{Name: "env", Usage: "add an environment variable, e.g. FOO=bar"},
+	cli.StringSliceFlag{Name: "args", Usage: "container process args, repeat in order"},
+	cli.StringSliceFlag{Name: "cap-add", Usage: "add a capability, e.g. CAP_SYS_ADMIN"},
+	cli.StringSliceFlag{Name: "cap-drop", Usage: "drop a capability, e.g. CAP_MKNOD"},
+	cli.BoolFlag{Name: "no-new-privileges", Usage: "set the no_new_privileges process attribute"},
+	cli.StringSliceFlag{Name: "tmpfs", Usage: "add a tmpfs mount, as dest[:opt[,opt...]]"},
+	cli.StringSliceFlag{Name: "bind", Usage: "add a bind mount, as src:dest[:opt[,opt...]]"},
+	cli.StringSliceFlag{Name: "linux-namespace-add", Usage: "add a linux namespace, as type[:path]"},
+	cli.StringSliceFlag{Name: "linux-namespace-remove", Usage: "remove a linux namespace, by type"},
+	cli.StringSliceFlag{Name: "linux-uidmapping", Usage: "add a uid mapping, as hostID:containerID:size"},
+	cli.StringSliceFlag{Name: "linux-gidmapping", Usage: "add a gid mapping, as hostID:containerID:size"},
+	cli.StringFlag{Name: "apparmor", Usage: "apparmor profile for the process"},
+	cli.StringFlag{Name: "selinux-label", Usage: "selinux label for the process"},
+	cli.StringFlag{Name: "seccomp-profile", Usage: "load a docker-style seccomp profile JSON file as the starting point, instead of the built-in default"},
+	cli.StringFlag{Name: "seccomp-default", Usage: "default seccomp action: kill, trap, errno, trace or allow"},
+	cli.StringSliceFlag{Name: "seccomp-syscalls", Usage: "seccomp rule, as action:syscall[,syscall...][@arg<index><op><value>]"},
+	cli.StringSliceFlag{Name: "seccomp-remove", Usage: "remove a syscall, by name, e.g. mount"},
+	cli.StringSliceFlag{Name: "seccomp-arch", Usage: "limit seccomp rules to an architecture, e.g. amd64"},
+	cli.StringFlag{Name: "linux-cpus", Usage: "CPUs in which to allow execution (cpuset.cpus)"},
+	cli.StringFlag{Name: "linux-mems", Usage: "memory nodes in which to allow execution (cpuset.mems)"},
+	cli.Int64Flag{Name: "linux-memory-limit", Usage: "memory limit, in bytes"},
+	cli.Int64Flag{Name: "linux-pids-limit", Usage: "maximum number of pids"},
+	cli.StringSliceFlag{Name: "hooks-prestart", Usage: "add a pre-start hook, as path[ arg...]"},
+}
+
+var generateCommand = cli.Command{
+	Name:  "generate",
+	Usage: "generate an OCI runtime spec file",
+	Flags: generateFlags,
+	Action: func(context *cli.Context) {
+		g := generate.New()
+
+		g.SetRootPath(context.String("rootfs"))
+		g.SetRootReadonly(context.Bool("read-only"))
+
+		if hostname := context.String("hostname"); hostname != "" {
+			g.SetHostname(hostname)
+		}
+		if cwd := context.String("cwd"); cwd != "" {
+			g.SetProcessCwd(cwd)
+		}
+		for _, env := range context.StringSlice("env") {
+			g.AddProcessEnv(env)
+		}
+		if args := context.StringSlice("args"); len(args) > 0 {
+			g.SetProcessArgs(args)
+		}
+		for _, c := range context.StringSlice("cap-add") {
+			g.AddProcessCapability(c)
+		}
+		for _, c := range context.StringSlice("cap-drop") {
+			g.DropProcessCapability(c)
+		}
+		g.SetProcessNoNewPrivileges(context.Bool("no-new-privileges"))
+		if profile := context.String("apparmor"); profile != "" {
+			g.SetProcessApparmorProfile(profile)
+		}
+		if label := context.String("selinux-label"); label != "" {
+			g.SetProcessSelinuxLabel(label)
+		}
+
+		for _, t := range context.StringSlice("tmpfs") {
+			parts := strings.SplitN(t, ":", 2)
+			var options []string
+			if len(parts) == 2 {
+				options = strings.Split(parts[1], ",")
+			}
+			g.AddTmpfsMount(parts[0], options)
+		}
+		for _, b := range context.StringSlice("bind") {
+			parts := strings.SplitN(b, ":", 3)
+			if len(parts) < 2 {
+				logrus.Fatalf("invalid --bind %q, want src:dest[:options]", b)
+			}
+			var options []string
+			if len(parts) == 3 {
+				options = strings.Split(parts[2], ",")
+			}
+			g.AddBindMount(parts[0], parts[1], options)
+		}
+
+		for _, n := range context.StringSlice("linux-namespace-add") {
+			parts := strings.SplitN(n, ":", 2)
+			path := ""
+			if len(parts) == 2 {
+				path = parts[1]
+			}
+			g.AddLinuxNamespace(rspec.LinuxNamespaceType(parts[0]), path)
+		}
+		for _, n := range context.StringSlice("linux-namespace-remove") {
+			g.RemoveLinuxNamespace(rspec.LinuxNamespaceType(n))
+		}
+
+		for _, m := range context.StringSlice("linux-uidmapping") {
+			hostID, containerID, size, err := parseIDMapping(m)
+			if err != nil {
+				logrus.Fatalf("invalid --linux-uidmapping %q: %v", m, err)
+			}
+			g.AddLinuxUIDMapping(hostID, containerID, size)
+		}
+		for _, m := range context.StringSlice("linux-gidmapping") {
+			hostID, containerID, size, err := parseIDMapping(m)
+			if err != nil {
+				logrus.Fatalf("invalid --linux-gidmapping %q: %v", m, err)
+			}
+			g.AddLinuxGIDMapping(hostID, containerID, size)
+		}
+
+		if cpus, mems := context.String("linux-cpus"), context.String("linux-mems"); cpus != "" || mems != "" {
+			g.SetLinuxResourcesCPU(cpus, mems)
+		}
+		if context.IsSet("linux-memory-limit") {
+			g.SetLinuxResourcesMemoryLimit(context.Int64("linux-memory-limit"))
+		}
+		if context.IsSet("linux-pids-limit") {
+			g.SetLinuxResourcesPidsLimit(context.Int64("linux-pids-limit"))
+		}
+
+		for _, h := range context.StringSlice("hooks-prestart") {
+			fields := strings.Fields(h)
+			if len(fields) == 0 {
+				continue
+			}
+			g.AddPrestartHook(fields[0], fields[1:])
+		}
+
+		setSeccomp(g, context)
+
+		output := context.String("output")
+		if output == "-" {
+			if err := g.Save(os.Stdout); err != nil {
+				logrus.Fatal(err)
+			}
+			return
+		}
+		if err := g.SaveToFile(output); err != nil {
+			logrus.Fatal(err)
+		}
+	},
+}
+
+func setSeccomp(g *generate.Generator, context *cli.Context) {
+	profilePath := context.String("seccomp-profile")
+	defaultAction := context.String("seccomp-default")
+	rules := context.StringSlice("seccomp-syscalls")
+	removals := context.StringSlice("seccomp-remove")
+	archs := context.StringSlice("seccomp-arch")
+	if profilePath == "" && defaultAction == "" && len(rules) == 0 && len(removals) == 0 && len(archs) == 0 {
+		return
+	}
+
+	var sec *rspec.Seccomp
+	if profilePath != "" {
+		content, err := ioutil.ReadFile(profilePath)
+		if err != nil {
+			logrus.Fatalf("reading --seccomp-profile %q: %v", profilePath, err)
+		}
+		sec, err = seccomp.ParseDockerProfile(content)
+		if err != nil {
+			logrus.Fatalf("parsing --seccomp-profile %q: %v", profilePath, err)
+		}
+	} else {
+		sec = seccomp.DefaultProfile()
+	}
+
+	if defaultAction != "" {
+		action, err := seccomp.ParseAction(defaultAction)
+		if err != nil {
+			logrus.Fatalf("invalid --seccomp-default %q: %v", defaultAction, err)
+		}
+		sec.DefaultAction = action
+	}
+
+	if len(archs) > 0 {
+		sec.Architectures = nil
+		for _, archName := range archs {
+			arch, err := seccomp.ParseArchitecture(archName)
+			if err != nil {
+				logrus.Fatalf("invalid --seccomp-arch %q: %v", archName, err)
+			}
+			sec.Architectures = append(sec.Architectures, arch)
+		}
+	}
+
+	for _, rule := range rules {
+		if err := seccomp.ParseShorthand(rule, sec); err != nil {
+			logrus.Fatalf("invalid --seccomp-syscalls %q: %v", rule, err)
+		}
+	}
+
+	for _, remove := range removals {
+		if err := seccomp.ParseRemove(remove, sec); err != nil {
+			logrus.Fatalf("invalid --seccomp-remove %q: %v", remove, err)
+		}
+	}
+
+	g.Spec.Linux.Seccomp = sec
+}
+
+func parseIDMapping(s string) (hostID, containerID, size uint32, err error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, 0, 0, fmt.Errorf("expected hostID:containerID:size")
+	}
+
+	values := make([]uint64, 3)
+	for i, p := range parts {
+		values[i], err = strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+	}
+
+	return uint32(values[0]), uint32(values[1]), uint32(values[2]), nil
+}
@@ -0,0 +1,295 @@
+// Package generate provides a fluent API for building an OCI runtime
+// config.json from sane defaults, for use by `oci-runtime-tool generate`
+// and other callers that need to construct a spec programmatically.
+package generate
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+
+	rspec "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// Generator wraps a spec, exposing incremental setters so a caller can build
+// up a config.json one flag/option at a time.
+type Generator struct {
+	Spec *rspec.Spec
+}
+
+// defaultCapabilities mirrors the capabilities runc grants a container by
+// default.
+var defaultCapabilities = []string{
+	"CAP_CHOWN",
+	"CAP_DAC_OVERRIDE",
+	"CAP_FSETID",
+	"CAP_FOWNER",
+	"CAP_MKNOD",
+	"CAP_NET_RAW",
+	"CAP_SETGID",
+	"CAP_SETUID",
+	"CAP_SETFCAP",
+	"CAP_SETPCAP",
+	"CAP_NET_BIND_SERVICE",
+	"CAP_SYS_CHROOT",
+	"CAP_KILL",
+	"CAP_AUDIT_WRITE",
+}
+
+var defaultNamespaces = []rspec.LinuxNamespace{
+	{Type: rspec.PIDNamespace},
+	{Type: rspec.NetworkNamespace},
+	{Type: rspec.IPCNamespace},
+	{Type: rspec.UTSNamespace},
+	{Type: rspec.MountNamespace},
+}
+
+var defaultMaskedPaths = []string{
+	"/proc/kcore",
+	"/proc/latency_stats",
+	"/proc/timer_list",
+	"/proc/timer_stats",
+	"/proc/sched_debug",
+	"/sys/firmware",
+}
+
+var defaultReadonlyPaths = []string{
+	"/proc/asound",
+	"/proc/bus",
+	"/proc/fs",
+	"/proc/irq",
+	"/proc/sys",
+	"/proc/sysrq-trigger",
+}
+
+// New returns a Generator seeded with a minimal, runnable spec: default
+// capabilities and rlimits, the standard namespaces, and the usual
+// masked/read-only /proc and /sys paths.
+func New() *Generator {
+	spec := rspec.Spec{
+		Version: "0.5.0",
+		Platform: rspec.Platform{
+			OS:   "linux",
+			Arch: "amd64",
+		},
+		Root: rspec.Root{
+			Path:     "rootfs",
+			Readonly: false,
+		},
+		Process: rspec.Process{
+			Terminal: true,
+			Cwd:      "/",
+			Env:      []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"},
+			Args:     []string{"sh"},
+			Rlimits: []rspec.Rlimit{
+				{Type: "RLIMIT_NOFILE", Hard: 1024, Soft: 1024},
+			},
+			Capabilities: append([]string{}, defaultCapabilities...),
+		},
+		Hostname: "oci-runtime-tool",
+		Linux: &rspec.Linux{
+			Namespaces:    append([]rspec.LinuxNamespace{}, defaultNamespaces...),
+			MaskedPaths:   append([]string{}, defaultMaskedPaths...),
+			ReadonlyPaths: append([]string{}, defaultReadonlyPaths...),
+			Resources:     &rspec.LinuxResources{},
+		},
+	}
+
+	return &Generator{Spec: &spec}
+}
+
+// NewFromSpec wraps an already-constructed spec, e.g. one loaded from an
+// existing config.json.
+func NewFromSpec(spec *rspec.Spec) *Generator {
+	return &Generator{Spec: spec}
+}
+
+func (g *Generator) initLinuxResources() {
+	if g.Spec.Linux.Resources == nil {
+		g.Spec.Linux.Resources = &rspec.LinuxResources{}
+	}
+}
+
+// SetRootPath sets the rootfs path.
+func (g *Generator) SetRootPath(path string) {
+	g.Spec.Root.Path = path
+}
+
+// SetRootReadonly sets whether the rootfs is mounted read-only.
+func (g *Generator) SetRootReadonly(readonly bool) {
+	g.Spec.Root.Readonly = readonly
+}
+
+// SetHostname sets the container's hostname.
+func (g *Generator) SetHostname(hostname string) {
+	g.Spec.Hostname = hostname
+}
+
+// SetProcessCwd sets the working directory of the container process.
+func (g *Generator) SetProcessCwd(cwd string) {
+	g.Spec.Process.Cwd = cwd
+}
+
+// AddProcessEnv appends an environment variable to the container process.
+func (g *Generator) AddProcessEnv(env string) {
+	g.Spec.Process.Env = append(g.Spec.Process.Env, env)
+}
+
+// SetProcessArgs replaces the container process' argv.
+func (g *Generator) SetProcessArgs(args []string) {
+	g.Spec.Process.Args = args
+}
+
+// SetProcessNoNewPrivileges sets the no_new_privileges prctl flag.
+func (g *Generator) SetProcessNoNewPrivileges(noNewPrivileges bool) {
+	g.Spec.Process.NoNewPrivileges = noNewPrivileges
+}
+
+// SetProcessApparmorProfile sets the apparmor profile for the process.
+func (g *Generator) SetProcessApparmorProfile(profile string) {
+	g.Spec.Process.ApparmorProfile = profile
+}
+
+// SetProcessSelinuxLabel sets the selinux label for the process.
+func (g *Generator) SetProcessSelinuxLabel(label string) {
+	g.Spec.Process.SelinuxLabel = label
+}
+
+// AddProcessCapability adds a capability to the process' capability set, if
+// it isn't already present.
+func (g *Generator) AddProcessCapability(c string) {
+	for _, capName := range g.Spec.Process.Capabilities {
+		if capName == c {
+			return
+		}
+	}
+
+	g.Spec.Process.Capabilities = append(g.Spec.Process.Capabilities, c)
+}
+
+// DropProcessCapability removes a capability from the process' capability
+// set.
+func (g *Generator) DropProcessCapability(c string) {
+	caps := g.Spec.Process.Capabilities[:0]
+	for _, capName := range g.Spec.Process.Capabilities {
+		if capName != c {
+			caps = append(caps, capName)
+		}
+	}
+
+	g.Spec.Process.Capabilities = caps
+}
+
+// AddTmpfsMount adds a tmpfs mount at destination.
+func (g *Generator) AddTmpfsMount(destination string, options []string) {
+	g.Spec.Mounts = append(g.Spec.Mounts, rspec.Mount{
+		Destination: destination,
+		Type:        "tmpfs",
+		Source:      "tmpfs",
+		Options:     options,
+	})
+}
+
+// AddBindMount adds a bind mount from source to destination.
+func (g *Generator) AddBindMount(source, destination string, options []string) {
+	g.Spec.Mounts = append(g.Spec.Mounts, rspec.Mount{
+		Destination: destination,
+		Type:        "bind",
+		Source:      source,
+		Options:     append([]string{"bind"}, options...),
+	})
+}
+
+// AddLinuxNamespace adds a Linux namespace to the spec, replacing any
+// existing entry of the same type.
+func (g *Generator) AddLinuxNamespace(ns rspec.LinuxNamespaceType, path string) {
+	g.RemoveLinuxNamespace(ns)
+	g.Spec.Linux.Namespaces = append(g.Spec.Linux.Namespaces, rspec.LinuxNamespace{Type: ns, Path: path})
+}
+
+// RemoveLinuxNamespace removes a Linux namespace from the spec.
+func (g *Generator) RemoveLinuxNamespace(ns rspec.LinuxNamespaceType) {
+	namespaces := g.Spec.Linux.Namespaces[:0]
+	for _, n := range g.Spec.Linux.Namespaces {
+		if n.Type != ns {
+			namespaces = append(namespaces, n)
+		}
+	}
+
+	g.Spec.Linux.Namespaces = namespaces
+}
+
+// AddLinuxUIDMapping adds a uid mapping to the spec.
+func (g *Generator) AddLinuxUIDMapping(hostID, containerID, size uint32) {
+	g.Spec.Linux.UIDMappings = append(g.Spec.Linux.UIDMappings, rspec.LinuxIDMapping{
+		HostID:      hostID,
+		ContainerID: containerID,
+		Size:        size,
+	})
+}
+
+// AddLinuxGIDMapping adds a gid mapping to the spec.
+func (g *Generator) AddLinuxGIDMapping(hostID, containerID, size uint32) {
+	g.Spec.Linux.GIDMappings = append(g.Spec.Linux.GIDMappings, rspec.LinuxIDMapping{
+		HostID:      hostID,
+		ContainerID: containerID,
+		Size:        size,
+	})
+}
+
+// SetLinuxCgroupsPath sets the cgroups path for the container.
+func (g *Generator) SetLinuxCgroupsPath(path string) {
+	g.Spec.Linux.CgroupsPath = path
+}
+
+// SetLinuxResourcesCPU sets the cpus and mems cpuset fields.
+func (g *Generator) SetLinuxResourcesCPU(cpus, mems string) {
+	g.initLinuxResources()
+	if g.Spec.Linux.Resources.CPU == nil {
+		g.Spec.Linux.Resources.CPU = &rspec.LinuxCPU{}
+	}
+	g.Spec.Linux.Resources.CPU.Cpus = cpus
+	g.Spec.Linux.Resources.CPU.Mems = mems
+}
+
+// SetLinuxResourcesMemoryLimit sets the memory limit, in bytes.
+func (g *Generator) SetLinuxResourcesMemoryLimit(limit int64) {
+	g.initLinuxResources()
+	if g.Spec.Linux.Resources.Memory == nil {
+		g.Spec.Linux.Resources.Memory = &rspec.LinuxMemory{}
+	}
+	g.Spec.Linux.Resources.Memory.Limit = &limit
+}
+
+// SetLinuxResourcesPidsLimit sets the maximum number of pids.
+func (g *Generator) SetLinuxResourcesPidsLimit(limit int64) {
+	g.initLinuxResources()
+	g.Spec.Linux.Resources.Pids = &rspec.LinuxPids{Limit: limit}
+}
+
+// AddPrestartHook adds a pre-start hook.
+func (g *Generator) AddPrestartHook(path string, args []string) {
+	g.Spec.Hooks.Prestart = append(g.Spec.Hooks.Prestart, rspec.Hook{Path: path, Args: args})
+}
+
+// Save writes the spec as indented JSON to w.
+func (g *Generator) Save(w io.Writer) error {
+	content, err := json.MarshalIndent(g.Spec, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(content)
+	return err
+}
+
+// SaveToFile writes the spec as indented JSON to the file at path.
+func (g *Generator) SaveToFile(path string) error {
+	content, err := json.MarshalIndent(g.Spec, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, content, os.FileMode(0644))
+}
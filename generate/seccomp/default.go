@@ -0,0 +1,58 @@
+package seccomp
+
+import rspec "github.com/opencontainers/runtime-spec/specs-go"
+
+// defaultSyscalls is a curated allow-list covering the syscalls a typical
+// unprivileged container needs, modeled on docker's default seccomp
+// profile. It is not an exhaustive reproduction of that profile's ~330
+// entries, but covers the common cases generate's defaults need.
+var defaultSyscalls = []string{
+	"accept", "accept4", "access", "alarm", "bind", "brk",
+	"capget", "capset", "chdir", "chmod", "chown", "clock_getres",
+	"clock_gettime", "clock_nanosleep", "close", "connect", "dup",
+	"dup2", "dup3", "epoll_create", "epoll_create1", "epoll_ctl",
+	"epoll_pwait", "epoll_wait", "eventfd", "eventfd2", "execve",
+	"execveat", "exit", "exit_group", "faccessat", "fadvise64",
+	"fallocate", "fchdir", "fchmod", "fchmodat", "fchown", "fchownat",
+	"fcntl", "fdatasync", "fgetxattr", "flistxattr", "flock", "fork",
+	"fstat", "fstatfs", "fsync", "ftruncate", "futex", "getcwd",
+	"getdents", "getdents64", "getegid", "geteuid", "getgid",
+	"getgroups", "getpeername", "getpgrp", "getpid", "getppid",
+	"getpriority", "getrandom", "getresgid", "getresuid", "getrlimit",
+	"getsockname", "getsockopt", "gettid", "gettimeofday", "getuid",
+	"getxattr", "ioctl", "kill", "link", "linkat", "listen", "listxattr",
+	"lseek", "lstat", "madvise", "mkdir", "mkdirat", "mmap", "mount",
+	"mprotect", "mremap", "msync", "munmap", "nanosleep", "open",
+	"openat", "pause", "pipe", "pipe2", "poll", "ppoll", "prctl",
+	"pread64", "preadv", "pselect6", "pwrite64", "pwritev", "read",
+	"readlink", "readlinkat", "readv", "recvfrom", "recvmsg", "rename",
+	"renameat", "rmdir", "rt_sigaction", "rt_sigpending",
+	"rt_sigprocmask", "rt_sigqueueinfo", "rt_sigreturn",
+	"rt_sigsuspend", "rt_sigtimedwait", "sched_getaffinity",
+	"sched_yield", "select", "sendmsg", "sendto", "setgid",
+	"setgroups", "setpriority", "setregid", "setresgid", "setresuid",
+	"setreuid", "setrlimit", "setsid", "setsockopt", "setuid",
+	"shutdown", "sigaltstack", "socket", "socketpair", "stat",
+	"statfs", "symlink", "symlinkat", "sync", "tgkill", "time",
+	"truncate", "umask", "uname", "unlink", "unlinkat", "utime",
+	"utimensat", "utimes", "vfork", "wait4", "waitid", "write", "writev",
+}
+
+// DefaultProfile returns a curated allow-list profile suitable as a starting
+// point for a new container, with ActErrno as the default action for
+// anything not explicitly allowed.
+func DefaultProfile() *rspec.Seccomp {
+	seccomp := &rspec.Seccomp{
+		DefaultAction: rspec.ActErrno,
+		Architectures: []rspec.Arch{rspec.ArchX86_64, rspec.ArchX86, rspec.ArchX32},
+	}
+
+	for _, name := range defaultSyscalls {
+		seccomp.Syscalls = append(seccomp.Syscalls, rspec.Syscall{
+			Name:   name,
+			Action: rspec.ActAllow,
+		})
+	}
+
+	return seccomp
+}
@@ -0,0 +1,283 @@
+// Package seccomp parses human-readable seccomp profiles -- both
+// docker-style JSON and the oci-runtime-tool CLI shorthand -- into
+// rspec.Seccomp structures suitable for splicing into spec.Linux.Seccomp.
+package seccomp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	rspec "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// ParseAction converts a CLI action name into an rspec.Action.
+func ParseAction(s string) (rspec.Action, error) {
+	switch s {
+	case "kill":
+		return rspec.ActKill, nil
+	case "trap":
+		return rspec.ActTrap, nil
+	case "errno":
+		return rspec.ActErrno, nil
+	case "trace":
+		return rspec.ActTrace, nil
+	case "allow":
+		return rspec.ActAllow, nil
+	default:
+		return "", fmt.Errorf("unknown seccomp action %q", s)
+	}
+}
+
+// ParseArchitecture converts a CLI architecture name into an rspec.Arch.
+func ParseArchitecture(s string) (rspec.Arch, error) {
+	switch s {
+	case "x86":
+		return rspec.ArchX86, nil
+	case "amd64":
+		return rspec.ArchX86_64, nil
+	case "x32":
+		return rspec.ArchX32, nil
+	case "arm":
+		return rspec.ArchARM, nil
+	case "arm64":
+		return rspec.ArchAARCH64, nil
+	case "mips":
+		return rspec.ArchMIPS, nil
+	case "mips64":
+		return rspec.ArchMIPS64, nil
+	case "mips64n32":
+		return rspec.ArchMIPS64N32, nil
+	case "mipsel":
+		return rspec.ArchMIPSEL, nil
+	case "mipsel64":
+		return rspec.ArchMIPSEL64, nil
+	case "mipsel64n32":
+		return rspec.ArchMIPSEL64N32, nil
+	default:
+		return "", fmt.Errorf("unknown seccomp architecture %q", s)
+	}
+}
+
+var argOps = []struct {
+	symbol string
+	op     rspec.Operant
+}{
+	{"!=", rspec.OpNotEqual},
+	{"<=", rspec.OpLessEqual},
+	{">=", rspec.OpGreaterEqual},
+	{"==", rspec.OpEqualTo},
+	{"&=", rspec.OpMaskedEqual},
+	{">", rspec.OpGreaterThan},
+}
+
+// ParseArguments parses a comma-separated list of "arg<index><op><value>"
+// constraints, e.g. "arg0!=0,arg1==3".
+func ParseArguments(s string) ([]rspec.Arg, error) {
+	var args []rspec.Arg
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !strings.HasPrefix(field, "arg") {
+			return nil, fmt.Errorf("seccomp argument %q must start with \"arg\"", field)
+		}
+
+		rest := field[len("arg"):]
+		var op rspec.Operant
+		var idx int
+		var found bool
+		for _, candidate := range argOps {
+			if i := strings.Index(rest, candidate.symbol); i >= 0 {
+				idx = i
+				op = candidate.op
+				found = true
+				rest = rest[:idx] + "\x00" + rest[idx+len(candidate.symbol):]
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("seccomp argument %q has no recognized operator", field)
+		}
+
+		parts := strings.SplitN(rest, "\x00", 2)
+		index, err := strconv.ParseUint(parts[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("seccomp argument %q: invalid index: %v", field, err)
+		}
+		value, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("seccomp argument %q: invalid value: %v", field, err)
+		}
+
+		args = append(args, rspec.Arg{Index: uint(index), Value: value, Op: op})
+	}
+
+	return args, nil
+}
+
+// ParseRemove strips the comma-separated syscall names in s from seccomp.
+func ParseRemove(s string, seccomp *rspec.Seccomp) error {
+	if seccomp == nil {
+		return fmt.Errorf("seccomp profile is nil")
+	}
+
+	remove := make(map[string]bool)
+	for _, name := range strings.Split(s, ",") {
+		remove[strings.TrimSpace(name)] = true
+	}
+
+	syscalls := seccomp.Syscalls[:0]
+	for _, sc := range seccomp.Syscalls {
+		if !remove[sc.Name] {
+			syscalls = append(syscalls, sc)
+		}
+	}
+	seccomp.Syscalls = syscalls
+
+	return nil
+}
+
+// ParseShorthand parses the CLI shorthand "action:syscall[,syscall...][@arg
+// constraints]" into one or more rspec.Syscall entries and appends them to
+// seccomp.
+func ParseShorthand(s string, seccomp *rspec.Seccomp) error {
+	actionAndRest := strings.SplitN(s, ":", 2)
+	if len(actionAndRest) != 2 {
+		return fmt.Errorf("seccomp rule %q must be of the form action:syscall[,syscall...][@args]", s)
+	}
+
+	action, err := ParseAction(actionAndRest[0])
+	if err != nil {
+		return err
+	}
+
+	namesAndArgs := strings.SplitN(actionAndRest[1], "@", 2)
+	var args []rspec.Arg
+	if len(namesAndArgs) == 2 {
+		args, err = ParseArguments(namesAndArgs[1])
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, name := range strings.Split(namesAndArgs[0], ",") {
+		seccomp.Syscalls = append(seccomp.Syscalls, rspec.Syscall{
+			Name:   strings.TrimSpace(name),
+			Action: action,
+			Args:   args,
+		})
+	}
+
+	return nil
+}
+
+// dockerSeccompProfile mirrors the subset of docker's seccomp profile JSON
+// schema needed to translate it into an rspec.Seccomp.
+type dockerSeccompProfile struct {
+	DefaultAction string   `json:"defaultAction"`
+	Architectures []string `json:"architectures"`
+	Syscalls      []struct {
+		Name   string   `json:"name"`
+		Names  []string `json:"names"`
+		Action string   `json:"action"`
+		Args   []struct {
+			Index    uint          `json:"index"`
+			Value    uint64        `json:"value"`
+			ValueTwo uint64        `json:"valueTwo"`
+			Op       rspec.Operant `json:"op"`
+		} `json:"args"`
+	} `json:"syscalls"`
+}
+
+// ParseDockerProfile converts a docker-style seccomp profile (as produced
+// by `docker run --security-opt seccomp=profile.json`) into an rspec.Seccomp.
+func ParseDockerProfile(content []byte) (*rspec.Seccomp, error) {
+	var profile dockerSeccompProfile
+	if err := json.Unmarshal(content, &profile); err != nil {
+		return nil, err
+	}
+
+	// docker profiles spell actions and architectures the libseccomp way
+	// (SCMP_ACT_ERRNO, SCMP_ARCH_X86_64); the CLI short names are accepted too.
+	parseAction := func(s string) (rspec.Action, error) {
+		return ParseAction(strings.ToLower(strings.TrimPrefix(s, "SCMP_ACT_")))
+	}
+	parseArch := func(s string) (rspec.Arch, error) {
+		if strings.HasPrefix(s, "SCMP_ARCH_") {
+			return rspec.Arch(s), nil
+		}
+		return ParseArchitecture(s)
+	}
+
+	seccomp := &rspec.Seccomp{}
+	if profile.DefaultAction != "" {
+		action, err := parseAction(profile.DefaultAction)
+		if err != nil {
+			return nil, err
+		}
+		seccomp.DefaultAction = action
+	}
+
+	for _, arch := range profile.Architectures {
+		a, err := parseArch(arch)
+		if err != nil {
+			return nil, err
+		}
+		seccomp.Architectures = append(seccomp.Architectures, a)
+	}
+
+	for _, s := range profile.Syscalls {
+		action, err := parseAction(s.Action)
+		if err != nil {
+			return nil, err
+		}
+
+		var sargs []rspec.Arg
+		for _, a := range s.Args {
+			sargs = append(sargs, rspec.Arg{Index: a.Index, Value: a.Value, ValueTwo: a.ValueTwo, Op: a.Op})
+		}
+
+		names := s.Names
+		if s.Name != "" {
+			names = append(names, s.Name)
+		}
+		for _, name := range names {
+			seccomp.Syscalls = append(seccomp.Syscalls, rspec.Syscall{Name: name, Action: action, Args: sargs})
+		}
+	}
+
+	return seccomp, nil
+}
+
+// SyscallCompare reports which syscall names allowed by a are missing from
+// b, and which are allowed by b but not a.
+func SyscallCompare(a, b *rspec.Seccomp) (missing, extra []string) {
+	inA := make(map[string]bool)
+	for _, sc := range a.Syscalls {
+		if sc.Action == rspec.ActAllow {
+			inA[sc.Name] = true
+		}
+	}
+	inB := make(map[string]bool)
+	for _, sc := range b.Syscalls {
+		if sc.Action == rspec.ActAllow {
+			inB[sc.Name] = true
+		}
+	}
+
+	for name := range inA {
+		if !inB[name] {
+			missing = append(missing, name)
+		}
+	}
+	for name := range inB {
+		if !inA[name] {
+			extra = append(extra, name)
+		}
+	}
+
+	return
+}
@@ -8,11 +8,9 @@ import (
 	"github.com/opencontainers/ocitools/units"
 )
 
-const bundleCacheDir = "./bundles"
-
 var runtimetestFlags = []cli.Flag{
 	cli.StringFlag{Name: "runtime, r", Usage: "runtime to be tested"},
-	cli.StringFlag{Name: "level, l", Usage: "-l=all: output all the details and statistics; -l=err-only: output failure details and statistics"},
+	cli.StringFlag{Name: "compliance-level, l", Value: "MUST", Usage: "lowest RFC 2119 keyword (MUST, SHOULD, MAY) treated as a hard failure; lower keywords are reported as TAP SKIPs"},
 	cli.BoolFlag{Name: "debug, d", Usage: "switch of debug mode, default to 'false', with '--debug' to enable debug mode"},
 }
 
@@ -28,13 +26,17 @@ var runtimeTestCommand = cli.Command{
 		if runtime = context.String("runtime"); runtime != "runc" {
 			logrus.Fatalf("'%v' is currently not supported", runtime)
 		}
-		level := context.String("level")
+		level, err := units.ParseComplianceLevel(context.String("compliance-level"))
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		units.SetFailThreshold(level)
 		setDebugMode(context.Bool("debug"))
 
 		units.LoadTestUnits("./cases.conf")
 
-		if err := os.MkdirAll(bundleCacheDir, os.ModePerm); err != nil {
-			logrus.Printf("Failed to create cache dir: %v", bundleCacheDir)
+		if err := os.MkdirAll(units.BundleCacheDir, os.ModePerm); err != nil {
+			logrus.Printf("Failed to create cache dir: %v", units.BundleCacheDir)
 			return
 		}
 
@@ -42,10 +44,10 @@ var runtimeTestCommand = cli.Command{
 			testTask(tu, runtime)
 		}
 
-		units.OutputResult(output)
+		units.OutputResult(os.Stdout)
 
-		if err := os.RemoveAll(bundleCacheDir); err != nil {
-			logrus.Fatalf("Failed to remove cache dir of bundles '%v': %v\n", bundleCacheDir, err)
+		if err := os.RemoveAll(units.BundleCacheDir); err != nil {
+			logrus.Fatalf("Failed to remove cache dir of bundles '%v': %v\n", units.BundleCacheDir, err)
 		}
 
 		if err := os.Remove("./config.json"); err != nil {
@@ -0,0 +1,269 @@
+package units
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	rspec "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// BundleCacheDir is where downloaded bundle fixtures are extracted before
+// being handed to the runtime under test.
+const BundleCacheDir = "./bundles"
+
+// ComplianceLevel mirrors the RFC 2119 keywords used by the runtime spec.
+// With the default FailThreshold, MUST violations are failures and
+// SHOULD/MAY violations are warnings.
+type ComplianceLevel int
+
+const (
+	// MUST assertions are failures when violated.
+	MUST ComplianceLevel = iota
+	// SHOULD assertions are warnings when violated, unless FailThreshold is
+	// SHOULD or MAY.
+	SHOULD
+	// MAY assertions are warnings when violated, unless FailThreshold is MAY.
+	MAY
+)
+
+func (c ComplianceLevel) String() string {
+	switch c {
+	case MUST:
+		return "MUST"
+	case SHOULD:
+		return "SHOULD"
+	case MAY:
+		return "MAY"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// ParseComplianceLevel parses the --compliance-level flag / cases.conf
+// column value.
+func ParseComplianceLevel(s string) (ComplianceLevel, error) {
+	switch strings.ToUpper(s) {
+	case "MUST":
+		return MUST, nil
+	case "SHOULD":
+		return SHOULD, nil
+	case "MAY":
+		return MAY, nil
+	default:
+		return MUST, fmt.Errorf("compliance level %q is not one of MUST, SHOULD, MAY", s)
+	}
+}
+
+// FailThreshold is the weakest compliance level still treated as a hard
+// failure: a violation at or stricter than FailThreshold is "not ok";
+// anything weaker is "ok ... # SKIP". It defaults to MUST and is changed with
+// SetFailThreshold, e.g. from the --compliance-level flag.
+var FailThreshold = MUST
+
+// SetFailThreshold sets the compliance level that check treats as a hard
+// failure.
+func SetFailThreshold(level ComplianceLevel) {
+	FailThreshold = level
+}
+
+// complianceTester accumulates TAP13 test points for a single TestUnit.
+// Violations weaker than FailThreshold (SHOULD/MAY, by default) are emitted as
+// "# SKIP" rather than "not ok" so CI consumers can distinguish warnings from
+// hard failures.
+type complianceTester struct {
+	name  string
+	count int
+	lines []string
+}
+
+func newComplianceTester(name string) *complianceTester {
+	return &complianceTester{name: name}
+}
+
+// check records one TAP test point named description. level is the RFC 2119
+// keyword the underlying spec clause uses; err is nil for a pass.
+func (t *complianceTester) check(description string, level ComplianceLevel, err error) {
+	t.count++
+
+	if err == nil {
+		t.lines = append(t.lines, fmt.Sprintf("ok %d - %s", t.count, description))
+		return
+	}
+
+	if level > FailThreshold {
+		t.lines = append(t.lines, fmt.Sprintf("ok %d - %s # SKIP %s", t.count, description, err))
+		return
+	}
+
+	t.lines = append(t.lines, fmt.Sprintf("not ok %d - %s", t.count, description))
+	t.lines = append(t.lines,
+		"  ---",
+		fmt.Sprintf("  message: %q", err.Error()),
+		"  ...",
+	)
+}
+
+func (t *complianceTester) finish() {
+	t.lines = append([]string{fmt.Sprintf("# %s", t.name), fmt.Sprintf("1..%d", t.count)}, t.lines...)
+	results = append(results, t.lines...)
+}
+
+// results accumulates every complianceTester's output across all units, to
+// be flushed by OutputResult once the whole suite has run.
+var results []string
+
+// OutputResult writes the accumulated TAP13 stream for every unit that has
+// run so far to w.
+func OutputResult(w io.Writer) {
+	fmt.Fprintln(w, "TAP version 13")
+	for _, line := range results {
+		fmt.Fprintln(w, line)
+	}
+}
+
+// runAssertions checks the bundle's declared mounts, namespaces, rlimits,
+// env vars and cgroup resource limits, plus write access under read-only
+// mounts, recording one TAP assertion per check.
+func runAssertions(tester *complianceTester, u *TestUnit, runtimeOutput []byte) {
+	configPath := filepath.Join(BundleCacheDir, u.Name, "config.json")
+	content, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		tester.check(fmt.Sprintf("%s: config.json is readable", u.Name), MUST, err)
+		return
+	}
+
+	var spec rspec.Spec
+	if err := json.Unmarshal(content, &spec); err != nil {
+		tester.check(fmt.Sprintf("%s: config.json is valid JSON", u.Name), MUST, err)
+		return
+	}
+
+	rootfs := filepath.Join(BundleCacheDir, u.Name, spec.Root.Path)
+
+	for _, m := range spec.Mounts {
+		desc := fmt.Sprintf("mount %q is reachable", m.Destination)
+		_, err := os.Stat(filepath.Join(rootfs, m.Destination))
+		tester.check(desc, u.Level, err)
+
+		if isReadOnlyMount(m) {
+			tester.check(fmt.Sprintf("mount %q rejects writes", m.Destination), u.Level, checkReadOnly(filepath.Join(rootfs, m.Destination)))
+		}
+	}
+
+	for _, ns := range spec.Linux.Namespaces {
+		tester.check(fmt.Sprintf("namespace %q is requested", ns.Type), u.Level, nil)
+	}
+
+	for _, rl := range spec.Process.Rlimits {
+		tester.check(fmt.Sprintf("rlimit %q soft limit does not exceed its hard limit", rl.Type), u.Level, checkRlimit(rl))
+	}
+
+	for _, env := range spec.Process.Env {
+		tester.check(fmt.Sprintf("env %q is visible to the container process", env), u.Level, checkEnvVisible(env, runtimeOutput))
+	}
+
+	if spec.Linux.Resources != nil {
+		tester.check("cgroup resources are applied", u.Level, checkCgroup(spec))
+	}
+}
+
+func isReadOnlyMount(m rspec.Mount) bool {
+	for _, opt := range m.Options {
+		if opt == "ro" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkReadOnly attempts to write a file under dir, treating EROFS/EACCES
+// as a passing "cannot write" determination.
+func checkReadOnly(dir string) error {
+	probe := filepath.Join(dir, ".oci-runtime-tool-rotest")
+	err := ioutil.WriteFile(probe, []byte("x"), 0644)
+	if err == nil {
+		os.Remove(probe)
+		return fmt.Errorf("wrote to %q, expected a read-only mount to refuse the write", probe)
+	}
+
+	if os.IsPermission(err) || strings.Contains(err.Error(), "read-only file system") {
+		return nil
+	}
+
+	return err
+}
+
+func checkRlimit(rl rspec.Rlimit) error {
+	if rl.Soft > rl.Hard {
+		return fmt.Errorf("soft limit %d exceeds hard limit %d", rl.Soft, rl.Hard)
+	}
+
+	return nil
+}
+
+func checkEnvVisible(env string, runtimeOutput []byte) error {
+	key := strings.SplitN(env, "=", 2)[0]
+	if !strings.Contains(string(runtimeOutput), key) {
+		return fmt.Errorf("%q was not found in the container's reported environment", key)
+	}
+
+	return nil
+}
+
+// checkCgroup reads back the memory and pids limits the runtime applied
+// under spec.Linux.CgroupsPath and compares them against what the spec
+// requested. A cgroup that no longer exists is treated as a pass rather than
+// a failure, since many runtimes tear it down once the container has exited.
+func checkCgroup(spec rspec.Spec) error {
+	logrus.Debugf("check cgroup resources for %s", spec.Linux.CgroupsPath)
+
+	if spec.Linux.CgroupsPath == "" {
+		return nil
+	}
+
+	res := spec.Linux.Resources
+	if res.Memory != nil && res.Memory.Limit != nil {
+		want := strconv.FormatInt(*res.Memory.Limit, 10)
+		if err := checkCgroupValue(spec.Linux.CgroupsPath, "memory", "memory.limit_in_bytes", want); err != nil {
+			return err
+		}
+	}
+
+	if res.Pids != nil {
+		want := strconv.FormatInt(res.Pids.Limit, 10)
+		if err := checkCgroupValue(spec.Linux.CgroupsPath, "pids", "pids.max", want); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkCgroupValue reads file from controller's hierarchy for cgroupsPath
+// and compares it against want.
+func checkCgroupValue(cgroupsPath, controller, file, want string) error {
+	path := filepath.Join("/sys/fs/cgroup", controller, cgroupsPath, file)
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		logrus.Debugf("%s no longer exists, assuming the container has already exited", path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if got := strings.TrimSpace(string(content)); got != want {
+		return fmt.Errorf("%s is %q, expected %q", path, got, want)
+	}
+
+	return nil
+}
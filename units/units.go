@@ -0,0 +1,99 @@
+// Package units loads and runs the runtimetest bundle fixtures used to probe
+// an OCI runtime for spec compliance, reporting each individual assertion
+// over TAP.
+package units
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// Units holds every TestUnit loaded by LoadTestUnits.
+var Units *[]*TestUnit
+
+// TestUnit describes a single bundle fixture to run against a runtime, plus
+// the compliance level its assertions are evaluated at.
+type TestUnit struct {
+	Name    string
+	Args    []string
+	Level   ComplianceLevel
+	runtime string
+}
+
+// LoadTestUnits reads the "name level arg0 arg1 ..." lines of path (comments
+// starting with '#' and blank lines are skipped) into Units.
+func LoadTestUnits(path string) {
+	units := []*TestUnit{}
+
+	f, err := os.Open(path)
+	if err != nil {
+		logrus.Fatalf("Failed to load test units from %q: %v", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			logrus.Warnf("Skipping malformed test unit line: %q", line)
+			continue
+		}
+
+		level, err := ParseComplianceLevel(fields[1])
+		if err != nil {
+			logrus.Warnf("Skipping test unit %q: %v", fields[0], err)
+			continue
+		}
+
+		units = append(units, &TestUnit{
+			Name:  fields[0],
+			Level: level,
+			Args:  fields[2:],
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		logrus.Fatalf("Failed to read test units from %q: %v", path, err)
+	}
+
+	Units = &units
+}
+
+// SetRuntime records which runtime binary Run should invoke.
+func (u *TestUnit) SetRuntime(runtime string) error {
+	if _, err := exec.LookPath(runtime); err != nil {
+		return fmt.Errorf("runtime %q is not executable: %v", runtime, err)
+	}
+
+	u.runtime = runtime
+	return nil
+}
+
+// Run executes the unit's bundle under the configured runtime and records
+// one TAP assertion per individual spec check.
+func (u *TestUnit) Run() {
+	logrus.Debugf("running test unit %q with args %v", u.Name, u.Args)
+
+	tester := newComplianceTester(u.Name)
+	defer tester.finish()
+
+	cmd := exec.Command(u.runtime, u.Args...)
+	cmd.Dir = BundleCacheDir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		tester.check(fmt.Sprintf("%s: runtime exits successfully", u.Name), MUST, fmt.Errorf("%v: %s", err, out))
+		return
+	}
+
+	runAssertions(tester, u, out)
+}
@@ -0,0 +1,574 @@
+// Package validate implements a reusable bundle validator for the OCI
+// runtime specification, suitable for vendoring by other Go projects.
+package validate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Sirupsen/logrus"
+	multierror "github.com/hashicorp/go-multierror"
+	rspec "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/syndtr/gocapability/capability"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Validator checks a single OCI bundle (its config.json and rootfs) for
+// conformance with the runtime specification.
+type Validator struct {
+	Spec         *rspec.Spec
+	BundlePath   string
+	HostSpecific bool
+	Platform     string
+}
+
+// posixRlimits are the RLIMIT_* names defined by POSIX and thus valid on
+// every supported platform.
+var posixRlimits = []string{
+	"RLIMIT_AS",
+	"RLIMIT_CORE",
+	"RLIMIT_CPU",
+	"RLIMIT_DATA",
+	"RLIMIT_FSIZE",
+	"RLIMIT_NOFILE",
+	"RLIMIT_STACK",
+}
+
+// linuxRlimits are the RLIMIT_* names additionally defined by Linux.
+var linuxRlimits = append(append([]string{}, posixRlimits...),
+	"RLIMIT_MEMLOCK",
+	"RLIMIT_MSGQUEUE",
+	"RLIMIT_NICE",
+	"RLIMIT_NPROC",
+	"RLIMIT_RSS",
+	"RLIMIT_RTPRIO",
+	"RLIMIT_RTTIME",
+	"RLIMIT_SIGPENDING",
+)
+
+func rlimitsFor(os string) []string {
+	if os == "linux" {
+		return linuxRlimits
+	}
+
+	return posixRlimits
+}
+
+// NewValidator creates a Validator for the given spec and bundle path.
+// hostSpecific enables checks that only make sense on the machine that will
+// run the container, and platform overrides the OS used for OS-specific
+// checks such as valid rlimit names (defaulting to spec.Platform.OS when
+// empty).
+func NewValidator(spec *rspec.Spec, bundlePath string, hostSpecific bool, platform string) *Validator {
+	return &Validator{
+		Spec:         spec,
+		BundlePath:   bundlePath,
+		HostSpecific: hostSpecific,
+		Platform:     platform,
+	}
+}
+
+// appendErr appends err to errs if err is non-nil. Unlike a bare
+// multierror.Append(errs, err), this never turns a nil errs into a non-nil
+// *multierror.Error when err is nil.
+func appendErr(errs error, err error) error {
+	if err == nil {
+		return errs
+	}
+
+	return multierror.Append(errs, err)
+}
+
+// CheckAll runs every check and returns the aggregated result.
+func (v *Validator) CheckAll() (errs error) {
+	errs = appendErr(errs, v.CheckRootfsPath())
+	errs = appendErr(errs, v.CheckMandatoryFields())
+	errs = appendErr(errs, v.CheckSemVer())
+	errs = appendErr(errs, v.CheckPlatform())
+	errs = appendErr(errs, v.CheckProcess())
+	errs = appendErr(errs, v.CheckLinux())
+	errs = appendErr(errs, v.CheckHooks())
+	errs = appendErr(errs, v.CheckJSONSchema())
+	errs = appendErr(errs, v.CheckHostSpecific())
+
+	return errs
+}
+
+// CheckRootfsPath validates that the bundle's rootfs exists and is a
+// directory.
+func (v *Validator) CheckRootfsPath() error {
+	logrus.Debugf("check rootfs path")
+
+	fi, err := os.Stat(v.rootfsPath())
+	if err != nil {
+		return fmt.Errorf("cannot find the root path %q: %v", v.rootfsPath(), err)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("the root path %q is not a directory", v.rootfsPath())
+	}
+
+	return nil
+}
+
+// CheckHostSpecific runs checks that are only meaningful on the machine that
+// will actually run the container: mounts, devices, cgroup controllers,
+// uid/gid mappings and sysctls are all checked against the live host rather
+// than just the bundle's declared config.json. It is a no-op unless
+// v.HostSpecific is set.
+func (v *Validator) CheckHostSpecific() error {
+	if !v.HostSpecific {
+		return nil
+	}
+
+	return checkHostSpecific(v.Spec, v.BundlePath)
+}
+
+func (v *Validator) rootfsPath() string {
+	return path.Join(v.BundlePath, v.Spec.Root.Path)
+}
+
+// effectiveOS returns v.Platform if set, else falls back to the bundle's own
+// declared spec.Platform.OS.
+func (v *Validator) effectiveOS() string {
+	if v.Platform != "" {
+		return v.Platform
+	}
+
+	return v.Spec.Platform.OS
+}
+
+// CheckSemVer validates that spec.Version follows SemVer v2.0.0.
+func (v *Validator) CheckSemVer() (errs error) {
+	logrus.Debugf("check version")
+
+	version := v.Spec.Version
+	re, _ := regexp.Compile(`^(\d+)?\.(\d+)?\.(\d+)?$`)
+	if ok := re.MatchString(version); !ok {
+		errs = multierror.Append(errs, fmt.Errorf("%q is not a valid version format, please read 'SemVer v2.0.0'", version))
+	}
+
+	return
+}
+
+// CheckPlatform validates that spec.Platform is a supported OS/Arch pair.
+func (v *Validator) CheckPlatform() (errs error) {
+	logrus.Debugf("check platform")
+
+	platform := v.Spec.Platform
+	validCombins := map[string][]string{
+		"darwin":    {"386", "amd64", "arm", "arm64"},
+		"dragonfly": {"amd64"},
+		"freebsd":   {"386", "amd64", "arm"},
+		"linux":     {"386", "amd64", "arm", "arm64", "ppc64", "ppc64le", "mips64", "mips64le"},
+		"netbsd":    {"386", "amd64", "arm"},
+		"openbsd":   {"386", "amd64", "arm"},
+		"plan9":     {"386", "amd64"},
+		"solaris":   {"amd64"},
+		"windows":   {"386", "amd64"}}
+	for os, archs := range validCombins {
+		if os == platform.OS {
+			for _, arch := range archs {
+				if arch == platform.Arch {
+					return nil
+				}
+			}
+			return multierror.Append(errs, fmt.Errorf("combination of %q and %q is invalid", platform.OS, platform.Arch))
+		}
+	}
+
+	return multierror.Append(errs, fmt.Errorf("operating system %q of the bundle is not supported yet", platform.OS))
+}
+
+// CheckHooks validates spec.Hooks.
+func (v *Validator) CheckHooks() (errs error) {
+	logrus.Debugf("check hooks")
+
+	hooks := v.Spec.Hooks
+	errs = appendErr(errs, v.checkEventHooks("pre-start", hooks.Prestart))
+	errs = appendErr(errs, v.checkEventHooks("post-start", hooks.Poststart))
+	errs = appendErr(errs, v.checkEventHooks("post-stop", hooks.Poststop))
+
+	return
+}
+
+func (v *Validator) checkEventHooks(hookType string, hooks []rspec.Hook) (errs error) {
+	for _, hook := range hooks {
+		if !filepath.IsAbs(hook.Path) {
+			errs = multierror.Append(errs, fmt.Errorf("the %s hook %v: is not an absolute path", hookType, hook.Path))
+		}
+
+		if v.HostSpecific {
+			fi, err := os.Stat(hook.Path)
+			if err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("cannot find %s hook: %v", hookType, hook.Path))
+			} else if fi.Mode()&0111 == 0 {
+				errs = multierror.Append(errs, fmt.Errorf("the %s hook %v: is not executable", hookType, hook.Path))
+			}
+		}
+
+		for _, env := range hook.Env {
+			if !EnvValid(env) {
+				errs = multierror.Append(errs, fmt.Errorf("env %q for hook %v is in the invalid form", env, hook.Path))
+			}
+		}
+	}
+
+	return
+}
+
+// CheckProcess validates spec.Process.
+func (v *Validator) CheckProcess() (errs error) {
+	logrus.Debugf("check process")
+
+	process := v.Spec.Process
+	if !path.IsAbs(process.Cwd) {
+		errs = multierror.Append(errs, fmt.Errorf("cwd %q is not an absolute path", process.Cwd))
+	}
+
+	for _, env := range process.Env {
+		if !EnvValid(env) {
+			errs = multierror.Append(errs, fmt.Errorf("env %q should be in the form of 'key=value'. The left hand side must consist solely of letters, digits, and underscores '_'", env))
+		}
+	}
+
+	for _, capName := range process.Capabilities {
+		if !CapValid(capName) {
+			errs = multierror.Append(errs, fmt.Errorf("capability %q is not valid, man capabilities(7)", capName))
+		}
+	}
+
+	for _, rlimit := range process.Rlimits {
+		if !RlimitValid(rlimit.Type, v.effectiveOS()) {
+			errs = multierror.Append(errs, fmt.Errorf("rlimit type %q is invalid", rlimit.Type))
+		}
+	}
+
+	if len(process.ApparmorProfile) > 0 {
+		profilePath := path.Join(v.rootfsPath(), "/etc/apparmor.d", process.ApparmorProfile)
+		if _, err := os.Stat(profilePath); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+
+	return
+}
+
+// CheckLinux validates spec.Linux.
+func (v *Validator) CheckLinux() (errs error) {
+	logrus.Debugf("check linux")
+
+	utsExists := false
+
+	logrus.Debugf("check uid mappings")
+	if len(v.Spec.Linux.UIDMappings) > 5 {
+		errs = multierror.Append(errs, fmt.Errorf("only 5 UID mappings are allowed (linux kernel restriction)"))
+	}
+
+	logrus.Debugf("check gid mappings")
+	if len(v.Spec.Linux.GIDMappings) > 5 {
+		errs = multierror.Append(errs, fmt.Errorf("only 5 GID mappings are allowed (linux kernel restriction)"))
+	}
+
+	for _, ns := range v.Spec.Linux.Namespaces {
+		if ns.Type == rspec.UTSNamespace {
+			utsExists = true
+			break
+		}
+	}
+
+	if v.effectiveOS() == "linux" && !utsExists && v.Spec.Hostname != "" {
+		errs = multierror.Append(errs, fmt.Errorf("on Linux, hostname requires a new UTS namespace to be specified as well"))
+	}
+
+	errs = appendErr(errs, checkLinux(v.Spec))
+
+	switch v.Spec.Linux.RootfsPropagation {
+	case "":
+	case "private":
+	case "rprivate":
+	case "slave":
+	case "rslave":
+	case "shared":
+	case "rshared":
+	default:
+		errs = multierror.Append(errs, fmt.Errorf("rootfsPropagation must be empty or one of \"private|rprivate|slave|rslave|shared|rshared\""))
+	}
+
+	return
+}
+
+// EnvValid returns whether env is a well-formed "key=value" environment
+// variable.
+func EnvValid(env string) bool {
+	items := strings.Split(env, "=")
+	if len(items) < 2 {
+		return false
+	}
+	for _, ch := range strings.TrimSpace(items[0]) {
+		if !unicode.IsDigit(ch) && !unicode.IsLetter(ch) && ch != '_' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CapValid returns whether capName is one of the capabilities listed by the
+// gocapability package's capability.List(). The running kernel is not
+// consulted.
+func CapValid(capName string) bool {
+	for _, c := range capability.List() {
+		if "CAP_"+strings.ToUpper(c.String()) == capName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RlimitValid returns whether rlimit is a recognized RLIMIT_* name for the
+// given target OS.
+func RlimitValid(rlimit string, os string) bool {
+	for _, val := range rlimitsFor(os) {
+		if val == rlimit {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NamespaceValid returns whether ns has a recognized namespace type.
+func NamespaceValid(ns rspec.Namespace) bool {
+	switch ns.Type {
+	case rspec.PIDNamespace:
+	case rspec.NetworkNamespace:
+	case rspec.MountNamespace:
+	case rspec.IPCNamespace:
+	case rspec.UTSNamespace:
+	case rspec.UserNamespace:
+	default:
+		return false
+	}
+
+	return true
+}
+
+// DeviceValid returns whether d is a well-formed device entry.
+func DeviceValid(d rspec.Device) bool {
+	switch d.Type {
+	case "b":
+	case "c":
+	case "u":
+		if d.Major <= 0 {
+			return false
+		}
+		if d.Minor <= 0 {
+			return false
+		}
+	case "p":
+		if d.Major > 0 || d.Minor > 0 {
+			return false
+		}
+	default:
+		return false
+	}
+
+	return true
+}
+
+// SeccompActionValid returns whether action is a recognized seccomp action.
+func SeccompActionValid(action rspec.Action) bool {
+	switch action {
+	case "":
+	case rspec.ActKill:
+	case rspec.ActTrap:
+	case rspec.ActErrno:
+	case rspec.ActTrace:
+	case rspec.ActAllow:
+	default:
+		return false
+	}
+
+	return true
+}
+
+// SyscallValid returns whether s is a well-formed seccomp syscall rule.
+func SyscallValid(s rspec.Syscall) bool {
+	if !SeccompActionValid(s.Action) {
+		return false
+	}
+	for _, arg := range s.Args {
+		switch arg.Op {
+		case rspec.OpNotEqual:
+		case rspec.OpLessEqual:
+		case rspec.OpEqualTo:
+		case rspec.OpGreaterEqual:
+		case rspec.OpGreaterThan:
+		case rspec.OpMaskedEqual:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func isStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct
+}
+
+func isStructPtr(t reflect.Type) bool {
+	return t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct
+}
+
+// CheckMandatoryUnit validates a single struct field against its "omitempty"
+// json tag, recursing into slice and map elements.
+func CheckMandatoryUnit(field reflect.Value, tagField reflect.StructField, parent string) (errs error) {
+	mandatory := !strings.Contains(tagField.Tag.Get("json"), "omitempty")
+	switch field.Kind() {
+	case reflect.Ptr:
+		if mandatory && field.IsNil() {
+			errs = multierror.Append(errs, fmt.Errorf("'%s.%s' should not be empty", parent, tagField.Name))
+		}
+	case reflect.String:
+		if mandatory && field.Len() == 0 {
+			errs = multierror.Append(errs, fmt.Errorf("'%s.%s' should not be empty", parent, tagField.Name))
+		}
+	case reflect.Slice:
+		if mandatory && field.Len() == 0 {
+			return multierror.Append(errs, fmt.Errorf("'%s.%s' should not be empty", parent, tagField.Name))
+		}
+		for index := 0; index < field.Len(); index++ {
+			mValue := field.Index(index)
+			if mValue.CanInterface() {
+				errs = appendErr(errs, CheckMandatory(mValue.Interface()))
+			}
+		}
+	case reflect.Map:
+		if mandatory && (field.IsNil() || field.Len() == 0) {
+			return multierror.Append(errs, fmt.Errorf("'%s.%s' should not be empty", parent, tagField.Name))
+		}
+
+		keys := field.MapKeys()
+		for index := 0; index < len(keys); index++ {
+			mValue := field.MapIndex(keys[index])
+			if mValue.CanInterface() {
+				errs = appendErr(errs, CheckMandatory(mValue.Interface()))
+			}
+		}
+	default:
+	}
+
+	return
+}
+
+// CheckMandatory recursively validates that every non-"omitempty" field of
+// obj is populated.
+func CheckMandatory(obj interface{}) (errs error) {
+	objT := reflect.TypeOf(obj)
+	objV := reflect.ValueOf(obj)
+	if isStructPtr(objT) {
+		objT = objT.Elem()
+		objV = objV.Elem()
+	} else if !isStruct(objT) {
+		return nil
+	}
+
+	for i := 0; i < objT.NumField(); i++ {
+		t := objT.Field(i).Type
+		if isStructPtr(t) && objV.Field(i).IsNil() {
+			if !strings.Contains(objT.Field(i).Tag.Get("json"), "omitempty") {
+				errs = multierror.Append(errs, fmt.Errorf("'%s.%s' should not be empty", objT.Name(), objT.Field(i).Name))
+			}
+		} else if (isStruct(t) || isStructPtr(t)) && objV.Field(i).CanInterface() {
+			errs = appendErr(errs, CheckMandatory(objV.Field(i).Interface()))
+		} else {
+			errs = appendErr(errs, CheckMandatoryUnit(objV.Field(i), objT.Field(i), objT.Name()))
+		}
+	}
+
+	return
+}
+
+// CheckMandatoryFields validates that every mandatory field of spec is set.
+func (v *Validator) CheckMandatoryFields() error {
+	logrus.Debugf("check mandatory fields")
+
+	return CheckMandatory(*v.Spec)
+}
+
+const schemaCacheDir = ".oci-runtime-tool-cache"
+
+// CheckJSONSchema validates the raw bundle config against the published
+// config-schema.json for the spec version declared in v.Spec.Version. A
+// locally cached copy of the schema is used when present; otherwise it is
+// fetched, and if that fails the check is skipped with a warning.
+func (v *Validator) CheckJSONSchema() (errs error) {
+	logrus.Debugf("check JSON schema")
+
+	content, err := ioutil.ReadFile(filepath.Join(v.BundlePath, "config.json"))
+	if err != nil {
+		return multierror.Append(errs, err)
+	}
+
+	schema, err := loadSchema(v.Spec.Version)
+	if err != nil {
+		logrus.Warnf("skipping JSON schema check: %v", err)
+		return nil
+	}
+
+	result, err := gojsonschema.Validate(schema, gojsonschema.NewBytesLoader(content))
+	if err != nil {
+		return multierror.Append(errs, err)
+	}
+
+	for _, re := range result.Errors() {
+		errs = multierror.Append(errs, fmt.Errorf("schema: %s", re))
+	}
+
+	return
+}
+
+// loadSchema fetches the config-schema.json for the given runtime-spec
+// version, caching it locally so repeated validations and offline use don't
+// require network access.
+func loadSchema(version string) (gojsonschema.JSONLoader, error) {
+	cacheFile := filepath.Join(os.TempDir(), schemaCacheDir, fmt.Sprintf("config-schema-%s.json", version))
+
+	if content, err := ioutil.ReadFile(cacheFile); err == nil {
+		return gojsonschema.NewBytesLoader(content), nil
+	}
+
+	url := fmt.Sprintf("https://raw.githubusercontent.com/opencontainers/runtime-spec/v%s/schema/config-schema.json", version)
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch config-schema.json for version %s and no local cache exists: %v", version, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch config-schema.json for version %s: HTTP %d", version, resp.StatusCode)
+	}
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cacheFile), 0755); err == nil {
+		_ = ioutil.WriteFile(cacheFile, content, 0644)
+	}
+
+	return gojsonschema.NewBytesLoader(content), nil
+}
@@ -0,0 +1,300 @@
+// +build linux
+
+package validate
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	multierror "github.com/hashicorp/go-multierror"
+	rspec "github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+)
+
+// checkHostSpecific runs the set of checks that require inspecting the
+// machine that will actually run the container.
+func checkHostSpecific(spec *rspec.Spec, bundlePath string) (errs error) {
+	errs = appendErr(errs, checkMountSources(spec, bundlePath))
+	errs = appendErr(errs, checkDevices(spec))
+	errs = appendErr(errs, checkCgroupControllers(spec))
+	errs = appendErr(errs, checkIDMappings(spec))
+	errs = appendErr(errs, checkSysctls(spec))
+
+	return
+}
+
+// resolveBundlePath joins p onto bundlePath (unless p is already absolute)
+// and resolves any symlinks. The resolved path is not checked to lie inside
+// the bundle.
+func resolveBundlePath(bundlePath, p string) (string, error) {
+	joined := p
+	if !filepath.IsAbs(p) {
+		joined = filepath.Join(bundlePath, p)
+	}
+
+	resolved, err := filepath.EvalSymlinks(joined)
+	if err != nil {
+		return joined, err
+	}
+
+	return resolved, nil
+}
+
+func checkMountSources(spec *rspec.Spec, bundlePath string) (errs error) {
+	for _, m := range spec.Mounts {
+		source, err := resolveBundlePath(bundlePath, m.Source)
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("mount source %q: %v", m.Source, err))
+			continue
+		}
+
+		if _, err := os.Stat(source); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("mount source %q: %v", m.Source, err))
+		}
+	}
+
+	return
+}
+
+func checkDevices(spec *rspec.Spec) (errs error) {
+	for _, d := range spec.Linux.Devices {
+		fi, err := os.Stat(d.Path)
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("device %q: %v", d.Path, err))
+			continue
+		}
+
+		st, ok := fi.Sys().(*syscall.Stat_t)
+		if !ok {
+			errs = multierror.Append(errs, fmt.Errorf("device %q: cannot read host device info", d.Path))
+			continue
+		}
+
+		major := int64(unix.Major(uint64(st.Rdev)))
+		minor := int64(unix.Minor(uint64(st.Rdev)))
+		if major != d.Major || minor != d.Minor {
+			errs = multierror.Append(errs, fmt.Errorf("device %q: host major:minor %d:%d does not match requested %d:%d", d.Path, major, minor, d.Major, d.Minor))
+		}
+
+		wantChar := d.Type == "c" || d.Type == "u"
+		wantBlock := d.Type == "b"
+		isChar := fi.Mode()&os.ModeCharDevice != 0
+		isBlock := fi.Mode()&os.ModeDevice != 0 && !isChar
+		if wantChar && !isChar {
+			errs = multierror.Append(errs, fmt.Errorf("device %q: host node is not a character device", d.Path))
+		}
+		if wantBlock && !isBlock {
+			errs = multierror.Append(errs, fmt.Errorf("device %q: host node is not a block device", d.Path))
+		}
+	}
+
+	return
+}
+
+// cgroupControllers maps the non-nil Resources sub-structs to the cgroup
+// controller name responsible for them.
+func cgroupControllers(r *rspec.LinuxResources) []string {
+	var controllers []string
+	if r.CPU != nil {
+		controllers = append(controllers, "cpu")
+	}
+	if r.Memory != nil {
+		controllers = append(controllers, "memory")
+	}
+	if r.Pids != nil {
+		controllers = append(controllers, "pids")
+	}
+	if r.BlockIO != nil {
+		controllers = append(controllers, "blkio")
+	}
+	if r.HugepageLimits != nil {
+		controllers = append(controllers, "hugetlb")
+	}
+	if r.Devices != nil {
+		controllers = append(controllers, "devices")
+	}
+	if r.Network != nil {
+		controllers = append(controllers, "net_cls,net_prio")
+	}
+
+	return controllers
+}
+
+// cgroupControllerMounts parses /proc/self/cgroup to find, for every
+// controller name, the host path of the hierarchy it is mounted under. On a
+// cgroup v1 host each named controller (or group of co-mounted controllers,
+// e.g. "net_cls,net_prio") has its own line; on a cgroup v2 host there is a
+// single unified hierarchy, and the controllers it provides are listed in
+// that hierarchy's cgroup.controllers file instead.
+func cgroupControllerMounts() (map[string]string, error) {
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	mounts := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(strings.TrimSpace(scanner.Text()), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		hierarchyID, names, cgroupPath := fields[0], fields[1], fields[2]
+
+		if hierarchyID == "0" && names == "" {
+			unified := filepath.Join("/sys/fs/cgroup", cgroupPath)
+			controllers, err := ioutil.ReadFile(filepath.Join(unified, "cgroup.controllers"))
+			if err != nil {
+				return nil, fmt.Errorf("reading cgroup.controllers: %v", err)
+			}
+			for _, name := range strings.Fields(string(controllers)) {
+				mounts[name] = unified
+			}
+			continue
+		}
+
+		for _, name := range strings.Split(names, ",") {
+			if name != "" {
+				mounts[name] = filepath.Join("/sys/fs/cgroup", name, cgroupPath)
+			}
+		}
+	}
+
+	return mounts, scanner.Err()
+}
+
+func checkCgroupControllers(spec *rspec.Spec) (errs error) {
+	if spec.Linux.Resources == nil {
+		return nil
+	}
+
+	mounts, err := cgroupControllerMounts()
+	if err != nil {
+		return appendErr(errs, fmt.Errorf("reading /proc/self/cgroup: %v", err))
+	}
+
+	for _, controller := range cgroupControllers(spec.Linux.Resources) {
+		// a co-mounted name like "net_cls,net_prio" is looked up by its
+		// first member name only.
+		path, ok := mounts[strings.SplitN(controller, ",", 2)[0]]
+		if !ok {
+			errs = appendErr(errs, fmt.Errorf("cgroup controller %q is not available on this host", controller))
+			continue
+		}
+
+		fi, err := os.Stat(path)
+		if err != nil {
+			errs = appendErr(errs, fmt.Errorf("cgroup controller %q is not mounted: %v", controller, err))
+			continue
+		}
+		if !fi.IsDir() {
+			errs = appendErr(errs, fmt.Errorf("cgroup controller %q: %q is not a directory", controller, path))
+			continue
+		}
+		if unix.Access(path, unix.W_OK) != nil {
+			errs = appendErr(errs, fmt.Errorf("cgroup controller %q is not writable at %q", controller, path))
+		}
+	}
+
+	return
+}
+
+// subIDRanges parses /etc/subuid or /etc/subgid entries for name.
+func subIDRanges(path, name string) (ranges [][2]int64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), ":")
+		if len(fields) != 3 || fields[0] != name {
+			continue
+		}
+		start, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		count, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		ranges = append(ranges, [2]int64{start, start + count})
+	}
+
+	return ranges, scanner.Err()
+}
+
+func withinRanges(id int64, size int64, ranges [][2]int64) bool {
+	for _, r := range ranges {
+		if id >= r[0] && id+size <= r[1] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func checkIDMappings(spec *rspec.Spec) (errs error) {
+	if len(spec.Linux.UIDMappings) == 0 && len(spec.Linux.GIDMappings) == 0 {
+		return nil
+	}
+
+	u, err := user.Current()
+	if err != nil {
+		return multierror.Append(errs, err)
+	}
+
+	if len(spec.Linux.UIDMappings) > 0 {
+		subuid, err := subIDRanges("/etc/subuid", u.Username)
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("reading /etc/subuid: %v", err))
+		} else {
+			for _, m := range spec.Linux.UIDMappings {
+				if !withinRanges(int64(m.HostID), int64(m.Size), subuid) {
+					errs = multierror.Append(errs, fmt.Errorf("uidMapping hostID %d size %d is not covered by /etc/subuid ranges for %q", m.HostID, m.Size, u.Username))
+				}
+			}
+		}
+	}
+
+	if len(spec.Linux.GIDMappings) > 0 {
+		subgid, err := subIDRanges("/etc/subgid", u.Username)
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("reading /etc/subgid: %v", err))
+		} else {
+			for _, m := range spec.Linux.GIDMappings {
+				if !withinRanges(int64(m.HostID), int64(m.Size), subgid) {
+					errs = multierror.Append(errs, fmt.Errorf("gidMapping hostID %d size %d is not covered by /etc/subgid ranges for %q", m.HostID, m.Size, u.Username))
+				}
+			}
+		}
+	}
+
+	return
+}
+
+func checkSysctls(spec *rspec.Spec) (errs error) {
+	for key := range spec.Linux.Sysctl {
+		path := filepath.Join("/proc/sys", strings.Replace(key, ".", "/", -1))
+		if _, err := os.Stat(path); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("sysctl %q: %v", key, err))
+			continue
+		}
+		if unix.Access(path, unix.W_OK) != nil {
+			errs = multierror.Append(errs, fmt.Errorf("sysctl %q is not writable at %q", key, path))
+		}
+	}
+
+	return
+}
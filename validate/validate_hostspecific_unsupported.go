@@ -0,0 +1,13 @@
+// +build !linux
+
+package validate
+
+import (
+	rspec "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// checkHostSpecific is a no-op on platforms other than Linux: cgroups,
+// sysctls and subuid/subgid mappings are Linux-specific concepts.
+func checkHostSpecific(spec *rspec.Spec, bundlePath string) error {
+	return nil
+}
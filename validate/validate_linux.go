@@ -0,0 +1,75 @@
+// +build linux
+
+package validate
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	multierror "github.com/hashicorp/go-multierror"
+	rspec "github.com/opencontainers/runtime-spec/specs-go"
+
+	"github.com/opencontainers/ocitools/generate/seccomp"
+)
+
+// checkLinux validates the namespace, device and seccomp portions of
+// spec.Linux, which only make sense to check on Linux.
+func checkLinux(spec *rspec.Spec) (errs error) {
+	for _, ns := range spec.Linux.Namespaces {
+		if !NamespaceValid(ns) {
+			errs = multierror.Append(errs, fmt.Errorf("namespace %v is invalid", ns))
+		}
+	}
+
+	for _, d := range spec.Linux.Devices {
+		if !DeviceValid(d) {
+			errs = multierror.Append(errs, fmt.Errorf("device %v is invalid", d))
+		}
+	}
+
+	if spec.Linux.Seccomp != nil {
+		errs = appendErr(errs, checkSeccomp(*spec.Linux.Seccomp))
+	}
+
+	return
+}
+
+func checkSeccomp(s rspec.Seccomp) (errs error) {
+	logrus.Debugf("check seccomp")
+
+	missing, extra := seccomp.SyscallCompare(seccomp.DefaultProfile(), &s)
+	if len(missing) > 0 {
+		logrus.Debugf("seccomp profile omits %d syscalls allowed by the built-in default profile: %v", len(missing), missing)
+	}
+	if len(extra) > 0 {
+		logrus.Debugf("seccomp profile allows %d syscalls beyond the built-in default profile: %v", len(extra), extra)
+	}
+
+	if !SeccompActionValid(s.DefaultAction) {
+		errs = multierror.Append(errs, fmt.Errorf("seccomp defaultAction %q is invalid", s.DefaultAction))
+	}
+	for _, syscall := range s.Syscalls {
+		if !SyscallValid(syscall) {
+			errs = multierror.Append(errs, fmt.Errorf("syscall %v is invalid", syscall))
+		}
+	}
+	for _, arch := range s.Architectures {
+		switch arch {
+		case rspec.ArchX86:
+		case rspec.ArchX86_64:
+		case rspec.ArchX32:
+		case rspec.ArchARM:
+		case rspec.ArchAARCH64:
+		case rspec.ArchMIPS:
+		case rspec.ArchMIPS64:
+		case rspec.ArchMIPS64N32:
+		case rspec.ArchMIPSEL:
+		case rspec.ArchMIPSEL64:
+		case rspec.ArchMIPSEL64N32:
+		default:
+			errs = multierror.Append(errs, fmt.Errorf("seccomp architecture %q is invalid", arch))
+		}
+	}
+
+	return
+}
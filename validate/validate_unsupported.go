@@ -0,0 +1,13 @@
+// +build !linux
+
+package validate
+
+import (
+	rspec "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// checkLinux is a no-op on platforms other than Linux: namespaces, devices
+// and seccomp are Linux-specific concepts, so there is nothing to check.
+func checkLinux(spec *rspec.Spec) error {
+	return nil
+}